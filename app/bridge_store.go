@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+/* ===========================
+   Bridge-Konten (Persistenz)
+=========================== */
+
+func initBridgeTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS bridge_accounts(
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			owner TEXT NOT NULL,
+			protocol TEXT NOT NULL,
+			server TEXT NOT NULL,
+			nick TEXT NOT NULL,
+			enc_password BLOB NOT NULL,
+			UNIQUE(owner, protocol, nick)
+		);
+	`)
+	return err
+}
+
+// bridgeSecretKey derives a secretbox key from the logged-in identity's
+// private key-exchange material, so bridge credentials at rest are tied to
+// the same secret that protects the user's own identity.
+func bridgeSecretKey(id *Identity) *[32]byte {
+	h := sha256.Sum256(append([]byte("bridge-accounts:"), id.KexPriv[:]...))
+	return &h
+}
+
+func encryptBridgePassword(id *Identity, password string) ([]byte, error) {
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := bridgeSecretKey(id)
+	return secretbox.Seal(nonce[:], []byte(password), &nonce, key), nil
+}
+
+func decryptBridgePassword(id *Identity, ciphertext []byte) (string, error) {
+	if len(ciphertext) < 24 {
+		return "", fmt.Errorf("bridge: ciphertext too short")
+	}
+	var nonce [24]byte
+	copy(nonce[:], ciphertext[:24])
+	key := bridgeSecretKey(id)
+	plain, ok := secretbox.Open(nil, ciphertext[24:], &nonce, key)
+	if !ok {
+		return "", fmt.Errorf("bridge: Entschlüsselung fehlgeschlagen")
+	}
+	return string(plain), nil
+}
+
+func saveBridgeAccount(db *sql.DB, id *Identity, acc Account) error {
+	encPW, err := encryptBridgePassword(id, acc.Password)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO bridge_accounts(owner,protocol,server,nick,enc_password) VALUES(?,?,?,?,?)
+		ON CONFLICT(owner,protocol,nick) DO UPDATE SET server=excluded.server, enc_password=excluded.enc_password
+	`, id.Username, acc.Protocol, acc.Server, acc.Nick, encPW)
+	return err
+}
+
+// loadBridgeAccounts returns every bridge account saved for id's username
+// with its password decrypted, so reconnectBridges can /bridge add them
+// again without prompting.
+func loadBridgeAccounts(db *sql.DB, id *Identity) ([]Account, error) {
+	rows, err := db.Query(`SELECT protocol, server, nick, enc_password FROM bridge_accounts WHERE owner = ?`, id.Username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Account
+	for rows.Next() {
+		var acc Account
+		var encPW []byte
+		if err := rows.Scan(&acc.Protocol, &acc.Server, &acc.Nick, &encPW); err != nil {
+			return nil, err
+		}
+		pw, err := decryptBridgePassword(id, encPW)
+		if err != nil {
+			return nil, err
+		}
+		acc.Password = pw
+		out = append(out, acc)
+	}
+	return out, nil
+}
+
+func listBridgeAccounts(db *sql.DB, owner string) ([]Account, error) {
+	rows, err := db.Query(`SELECT protocol, server, nick FROM bridge_accounts WHERE owner = ?`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Account
+	for rows.Next() {
+		var acc Account
+		if err := rows.Scan(&acc.Protocol, &acc.Server, &acc.Nick); err != nil {
+			return nil, err
+		}
+		out = append(out, acc)
+	}
+	return out, nil
+}
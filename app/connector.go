@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+/* ===========================
+   Bridge: externe Chat-Netzwerke
+=========================== */
+
+// Connector ist die Brücke zu einem externen Chat-Netzwerk (IRC, XMPP, ...).
+// Implementierungen übersetzen eingehende Events in Message-Werte, die über
+// denselben broadcast()/handleConnection-Pfad laufen wie native P2P-Chats,
+// und gehen in die andere Richtung mit SendMessage.
+type Connector interface {
+	Protocol() string
+	Connect(acc Account) error
+	SendMessage(room, content string) error
+	Join(room string) error
+	Leave(room string) error
+	// OnMessage registriert den Callback, der für jede eingehende
+	// Bridge-Nachricht aufgerufen wird.
+	OnMessage(func(Message))
+	// Rooms listet die aktuell beigetretenen Räume, damit lokal getippte
+	// Nachrichten ohne zusätzlichen Zustand beim Aufrufer in jeden
+	// gebridgten Raum gespiegelt werden können.
+	Rooms() []string
+}
+
+// Account bildet einen lokalen PostScriptum-User auf ein externes Konto ab.
+type Account struct {
+	Protocol string
+	Server   string
+	Nick     string
+	Password string // nur im Speicher/DB verschlüsselt, nie im Klartext geloggt
+}
+
+var bridgeConnectors = map[string]func() Connector{
+	"irc":  func() Connector { return newIRCConnector() },
+	"xmpp": func() Connector { return newXMPPConnector() },
+}
+
+func newConnector(protocol string) (Connector, error) {
+	factory, ok := bridgeConnectors[protocol]
+	if !ok {
+		return nil, fmt.Errorf("unbekanntes Bridge-Protokoll %q", protocol)
+	}
+	return factory(), nil
+}
+
+// reconnectBridges re-establishes every bridge account id saved in a
+// previous session, so /bridge add only has to be run once per account
+// instead of prompting for the password again on every start.
+func reconnectBridges(db *sql.DB, id *Identity) {
+	accs, err := loadBridgeAccounts(db, id)
+	if err != nil {
+		fmt.Println(cp("r", "Gespeicherte Bridges konnten nicht geladen werden: ", err))
+		return
+	}
+	for _, acc := range accs {
+		conn, err := newConnector(acc.Protocol)
+		if err != nil {
+			fmt.Println(cp("r", err))
+			continue
+		}
+		conn.OnMessage(bridgeToBroadcast)
+		if err := conn.Connect(acc); err != nil {
+			fmt.Println(cp("r", "Bridge ", acc.Protocol, " (", acc.Nick, "@", acc.Server, ") konnte nicht wiederverbunden werden: ", err))
+			continue
+		}
+		activeBridges[acc.Protocol] = conn
+		fmt.Println(cp("g", "Bridge ", acc.Protocol, " wiederverbunden als ", acc.Nick, "@", acc.Server, "."))
+	}
+}
+
+// bridgeToBroadcast ist der gemeinsame Callback, den jeder Connector beim
+// Empfang einer externen Nachricht aufruft: sie wird lokal angezeigt und
+// läuft zusätzlich durch broadcast(), damit sie wie jede native Nachricht
+// bei allen P2P-Peers ankommt.
+func bridgeToBroadcast(msg Message) {
+	fmt.Printf("%s[%s]%s %s\n", colorMap["m"], messageLabel(msg), reset, msg.Content)
+	broadcast(msg)
+}
+
+// bridgeOutgoing spiegelt eine lokal getippte Nachricht in jeden Raum, dem
+// wir über eine aktive Bridge beigetreten sind – die Umkehrung von
+// bridgeToBroadcast, damit die Brücke in beide Richtungen funktioniert.
+func bridgeOutgoing(msg Message) {
+	for proto, conn := range activeBridges {
+		for _, room := range conn.Rooms() {
+			if err := conn.SendMessage(room, msg.Content); err != nil {
+				fmt.Println(cp("r", "[", proto, "] Senden an ", room, " fehlgeschlagen: ", err))
+			}
+		}
+	}
+}
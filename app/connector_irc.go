@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ircConnector is a minimal but functional IRC Connector: it opens a real
+// TCP connection, registers with NICK/USER, answers PING with PONG to stay
+// connected, and bridges PRIVMSGs in both directions via the Connector
+// interface.
+type ircConnector struct {
+	acc Account
+
+	mu   sync.Mutex // schützt conn gegen gleichzeitige Schreibzugriffe
+	conn net.Conn
+
+	rooms map[string]bool
+	onMsg func(Message)
+}
+
+func newIRCConnector() *ircConnector {
+	return &ircConnector{rooms: map[string]bool{}}
+}
+
+func (c *ircConnector) Protocol() string { return "irc" }
+
+func (c *ircConnector) Connect(acc Account) error {
+	c.acc = acc
+
+	addr := acc.Server
+	if !strings.Contains(addr, ":") {
+		addr = net.JoinHostPort(addr, "6667")
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("irc: Verbindung zu %s fehlgeschlagen: %w", addr, err)
+	}
+	c.conn = conn
+
+	if acc.Password != "" {
+		if err := c.send("PASS " + acc.Password); err != nil {
+			return err
+		}
+	}
+	if err := c.send("NICK " + acc.Nick); err != nil {
+		return err
+	}
+	if err := c.send(fmt.Sprintf("USER %s 0 * :%s", acc.Nick, acc.Nick)); err != nil {
+		return err
+	}
+
+	go c.readLoop()
+	fmt.Println(cp("m", "[irc] verbunden mit ", acc.Server, " als ", acc.Nick))
+	return nil
+}
+
+// readLoop liest Zeilen vom Server, bis die Verbindung endet. PINGs werden
+// sofort beantwortet, PRIVMSGs werden in Message-Werte übersetzt und an
+// onMsg weitergereicht.
+func (c *ircConnector) readLoop() {
+	sc := bufio.NewScanner(c.conn)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r\n")
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "PING ") {
+			_ = c.send("PONG " + strings.TrimPrefix(line, "PING "))
+			continue
+		}
+		nick, room, text, ok := parseIRCPrivmsg(line)
+		if !ok || c.onMsg == nil {
+			continue
+		}
+		c.onMsg(Message{From: nick, Content: text, Protocol: "irc", Room: room})
+	}
+}
+
+// parseIRCPrivmsg zerlegt eine Server-Zeile der Form
+// ":nick!user@host PRIVMSG #room :text" in ihre Bestandteile.
+func parseIRCPrivmsg(line string) (nick, room, text string, ok bool) {
+	if !strings.HasPrefix(line, ":") {
+		return "", "", "", false
+	}
+	parts := strings.SplitN(line[1:], " ", 2)
+	if len(parts) != 2 {
+		return "", "", "", false
+	}
+	prefix, rest := parts[0], parts[1]
+	if !strings.HasPrefix(rest, "PRIVMSG ") {
+		return "", "", "", false
+	}
+	fields := strings.SplitN(strings.TrimPrefix(rest, "PRIVMSG "), " :", 2)
+	if len(fields) != 2 {
+		return "", "", "", false
+	}
+	nick = prefix
+	if i := strings.Index(prefix, "!"); i >= 0 {
+		nick = prefix[:i]
+	}
+	return nick, fields[0], fields[1], true
+}
+
+func (c *ircConnector) send(line string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := fmt.Fprintf(c.conn, "%s\r\n", line)
+	return err
+}
+
+func (c *ircConnector) Join(room string) error {
+	if err := c.send("JOIN " + room); err != nil {
+		return err
+	}
+	c.rooms[room] = true
+	fmt.Println(cp("m", "[irc] ", c.acc.Nick, " ist ", room, " beigetreten"))
+	return nil
+}
+
+func (c *ircConnector) Leave(room string) error {
+	if err := c.send("PART " + room); err != nil {
+		return err
+	}
+	delete(c.rooms, room)
+	return nil
+}
+
+func (c *ircConnector) SendMessage(room, content string) error {
+	if !c.rooms[room] {
+		return fmt.Errorf("irc: nicht in Raum %q", room)
+	}
+	return c.send(fmt.Sprintf("PRIVMSG %s :%s", room, content))
+}
+
+func (c *ircConnector) OnMessage(fn func(Message)) { c.onMsg = fn }
+
+func (c *ircConnector) Rooms() []string {
+	out := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		out = append(out, room)
+	}
+	return out
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// xmppConnector is a minimal but functional XMPP (Jabber) Connector: it
+// opens a real TCP connection, negotiates the XML stream, authenticates via
+// SASL PLAIN, joins MUC rooms via presence and bridges <message> stanzas in
+// both directions via the Connector interface.
+type xmppConnector struct {
+	acc Account
+
+	mu   sync.Mutex // schützt conn gegen gleichzeitige Schreibzugriffe
+	conn net.Conn
+	r    *bufio.Reader // persistent über Stream-Neuaufbau hinweg, s. openStream
+	dec  *xml.Decoder
+
+	rooms map[string]bool
+	onMsg func(Message)
+}
+
+func newXMPPConnector() *xmppConnector {
+	return &xmppConnector{rooms: map[string]bool{}}
+}
+
+func (c *xmppConnector) Protocol() string { return "xmpp" }
+
+func (c *xmppConnector) Connect(acc Account) error {
+	c.acc = acc
+
+	addr := acc.Server
+	if !strings.Contains(addr, ":") {
+		addr = net.JoinHostPort(addr, "5222")
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("xmpp: Verbindung zu %s fehlgeschlagen: %w", addr, err)
+	}
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+
+	domain := acc.Server
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		domain = host
+	}
+
+	if err := c.openStream(domain); err != nil {
+		return err
+	}
+	if err := c.authenticate(); err != nil {
+		return err
+	}
+	// Nach erfolgreichem SASL verlangt XMPP einen neu eröffneten Stream.
+	if err := c.openStream(domain); err != nil {
+		return err
+	}
+	if err := c.bind(); err != nil {
+		return err
+	}
+	if err := c.writeRaw("<presence/>"); err != nil {
+		return err
+	}
+
+	go c.readLoop()
+	fmt.Println(cp("m", "[xmpp] verbunden mit ", acc.Server, " als ", acc.Nick))
+	return nil
+}
+
+func (c *xmppConnector) writeRaw(s string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := fmt.Fprint(c.conn, s)
+	return err
+}
+
+// openStream eröffnet (bzw. nach SASL neu eröffnet) den XML-Stream und
+// liest, bis der Server mit </stream:features> seine Fähigkeiten fertig
+// angekündigt hat. Der Decoder wird dabei immer über c.r (nicht direkt über
+// c.conn) neu aufgesetzt: c.r bleibt über den Neuaufbau hinweg derselbe
+// bufio.Reader, sonst gingen Bytes verloren, die der alte Decoder bereits
+// vom Socket gepuffert, aber noch nicht als Token ausgeliefert hatte.
+func (c *xmppConnector) openStream(domain string) error {
+	if err := c.writeRaw(fmt.Sprintf(
+		"<stream:stream to='%s' xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>",
+		domain)); err != nil {
+		return err
+	}
+	c.dec = xml.NewDecoder(c.r)
+	for {
+		tok, err := c.dec.Token()
+		if err != nil {
+			return fmt.Errorf("xmpp: Stream-Verhandlung fehlgeschlagen: %w", err)
+		}
+		if end, ok := tok.(xml.EndElement); ok && end.Name.Local == "features" {
+			return nil
+		}
+	}
+}
+
+func (c *xmppConnector) authenticate() error {
+	payload := "\x00" + c.acc.Nick + "\x00" + c.acc.Password
+	b64 := base64.StdEncoding.EncodeToString([]byte(payload))
+	if err := c.writeRaw(fmt.Sprintf(
+		"<auth xmlns='urn:ietf:params:xml:ns:xmpp-sasl' mechanism='PLAIN'>%s</auth>", b64)); err != nil {
+		return err
+	}
+	for {
+		tok, err := c.dec.Token()
+		if err != nil {
+			return fmt.Errorf("xmpp: SASL-Antwort fehlgeschlagen: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "success":
+			return nil
+		case "failure":
+			return fmt.Errorf("xmpp: SASL-Authentifizierung von %s abgelehnt", c.acc.Nick)
+		}
+	}
+}
+
+// bind bindet eine Ressource und wartet auf die Bestätigung, bevor wir uns
+// als einsatzbereit betrachten.
+func (c *xmppConnector) bind() error {
+	if err := c.writeRaw("<iq type='set' id='bind1'><bind xmlns='urn:ietf:params:xml:ns:xmpp-bind'/></iq>"); err != nil {
+		return err
+	}
+	for {
+		tok, err := c.dec.Token()
+		if err != nil {
+			return fmt.Errorf("xmpp: Bind fehlgeschlagen: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "iq" {
+			continue
+		}
+		for _, a := range start.Attr {
+			if a.Name.Local == "id" && a.Value == "bind1" {
+				_ = c.dec.Skip()
+				return nil
+			}
+		}
+		_ = c.dec.Skip()
+	}
+}
+
+// readLoop liest Stanzas vom Stream, bis die Verbindung endet, und reicht
+// jede <message type='groupchat'> mit Body an onMsg weiter.
+func (c *xmppConnector) readLoop() {
+	for {
+		tok, err := c.dec.Token()
+		if err != nil {
+			return
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "message" {
+			continue
+		}
+		var from, msgType string
+		for _, a := range start.Attr {
+			switch a.Name.Local {
+			case "from":
+				from = a.Value
+			case "type":
+				msgType = a.Value
+			}
+		}
+		if msgType != "groupchat" {
+			_ = c.dec.Skip()
+			continue
+		}
+		var stanza struct {
+			Body string `xml:"body"`
+		}
+		if err := c.dec.DecodeElement(&stanza, &start); err != nil || stanza.Body == "" {
+			continue
+		}
+		room, nick := from, from
+		if i := strings.LastIndex(from, "/"); i >= 0 {
+			room, nick = from[:i], from[i+1:]
+		}
+		if c.onMsg != nil {
+			c.onMsg(Message{From: nick, Content: stanza.Body, Protocol: "xmpp", Room: room})
+		}
+	}
+}
+
+func (c *xmppConnector) Join(room string) error {
+	if err := c.writeRaw(fmt.Sprintf("<presence to='%s'/>", xmlAttrEscape(room+"/"+c.acc.Nick))); err != nil {
+		return err
+	}
+	c.rooms[room] = true
+	fmt.Println(cp("m", "[xmpp] ", c.acc.Nick, " ist ", room, " beigetreten"))
+	return nil
+}
+
+func (c *xmppConnector) Leave(room string) error {
+	if err := c.writeRaw(fmt.Sprintf("<presence type='unavailable' to='%s'/>", xmlAttrEscape(room+"/"+c.acc.Nick))); err != nil {
+		return err
+	}
+	delete(c.rooms, room)
+	return nil
+}
+
+func (c *xmppConnector) SendMessage(room, content string) error {
+	if !c.rooms[room] {
+		return fmt.Errorf("xmpp: nicht in Raum %q", room)
+	}
+	stanza := fmt.Sprintf("<message to='%s' type='groupchat'><body>%s</body></message>",
+		xmlAttrEscape(room), xmlAttrEscape(content))
+	return c.writeRaw(stanza)
+}
+
+func (c *xmppConnector) OnMessage(fn func(Message)) { c.onMsg = fn }
+
+func (c *xmppConnector) Rooms() []string {
+	out := make([]string, 0, len(c.rooms))
+	for room := range c.rooms {
+		out = append(out, room)
+	}
+	return out
+}
+
+func xmlAttrEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
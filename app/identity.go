@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+/* ===========================
+   Identität / Schlüssel
+=========================== */
+
+// Identity bündelt die Langzeit-Schlüssel eines Users: Ed25519 zum Signieren
+// von Handshake-Nonces und X25519 (nacl/box) für den Schlüsselaustausch.
+type Identity struct {
+	Username string
+	SignPub  ed25519.PublicKey
+	SignPriv ed25519.PrivateKey
+	KexPub   *[32]byte
+	KexPriv  *[32]byte
+}
+
+func generateIdentity(username string) (*Identity, error) {
+	signPub, signPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	kexPub, kexPriv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Username: username,
+		SignPub:  signPub,
+		SignPriv: signPriv,
+		KexPub:   kexPub,
+		KexPriv:  kexPriv,
+	}, nil
+}
+
+// Fingerprint liefert einen ssh-chat-ähnlichen Fingerprint der öffentlichen
+// Schlüssel: SHA-256 über SignPub||KexPub, hex-codiert und in Gruppen.
+func (id *Identity) Fingerprint() string {
+	return fingerprintOf(id.SignPub, id.KexPub[:])
+}
+
+func fingerprintOf(signPub, kexPub []byte) string {
+	h := sha256.Sum256(append(append([]byte{}, signPub...), kexPub...))
+	raw := hex.EncodeToString(h[:])
+	groups := make([]string, 0, len(raw)/4)
+	for i := 0; i < len(raw); i += 4 {
+		end := i + 4
+		if end > len(raw) {
+			end = len(raw)
+		}
+		groups = append(groups, raw[i:end])
+	}
+	out := ""
+	for i, g := range groups {
+		if i > 0 {
+			out += ":"
+		}
+		out += g
+	}
+	return out
+}
+
+// argon2Key leitet einen 32-Byte secretbox-Schlüssel aus Passwort + Salt ab.
+func argon2Key(password string, salt []byte) *[32]byte {
+	raw := argon2.IDKey([]byte(password), salt, 1, 64*1024, 4, 32)
+	var key [32]byte
+	copy(key[:], raw)
+	return &key
+}
+
+// sealIdentity verschlüsselt die privaten Schlüsselhälften mit einem aus dem
+// Login-Passwort abgeleiteten Schlüssel, damit ein DB-Leak allein nicht
+// ausreicht, um die Identität zu kompromittieren.
+func sealIdentity(id *Identity, password string) (ciphertext, salt []byte, err error) {
+	salt = make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	key := argon2Key(password, salt)
+
+	plain := make([]byte, 0, ed25519.PrivateKeySize+32)
+	plain = append(plain, id.SignPriv...)
+	plain = append(plain, id.KexPriv[:]...)
+
+	var nonce [24]byte
+	if _, err = rand.Read(nonce[:]); err != nil {
+		return nil, nil, err
+	}
+	sealed := secretbox.Seal(nonce[:], plain, &nonce, key)
+	return sealed, salt, nil
+}
+
+// openIdentity kehrt sealIdentity um und liefert die private Identität.
+func openIdentity(username string, signPub ed25519.PublicKey, kexPub *[32]byte, ciphertext, salt []byte, password string) (*Identity, error) {
+	if len(ciphertext) < 24 {
+		return nil, errors.New("identity: ciphertext too short")
+	}
+	key := argon2Key(password, salt)
+	var nonce [24]byte
+	copy(nonce[:], ciphertext[:24])
+
+	plain, ok := secretbox.Open(nil, ciphertext[24:], &nonce, key)
+	if !ok {
+		return nil, errors.New("identity: falsches Passwort oder beschädigte Daten")
+	}
+	if len(plain) != ed25519.PrivateKeySize+32 {
+		return nil, errors.New("identity: unerwartete Schlüssellänge")
+	}
+	id := &Identity{
+		Username: username,
+		SignPub:  signPub,
+		SignPriv: ed25519.PrivateKey(plain[:ed25519.PrivateKeySize]),
+		KexPub:   kexPub,
+	}
+	var kexPriv [32]byte
+	copy(kexPriv[:], plain[ed25519.PrivateKeySize:])
+	id.KexPriv = &kexPriv
+	return id, nil
+}
+
+func printFingerprint(id *Identity) {
+	fmt.Println(cp("c", "Dein Fingerprint: ", id.Fingerprint()))
+}
@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -19,11 +21,23 @@ import (
 type Message struct {
 	From    string `json:"from"`
 	Content string `json:"content"`
+	// Protocol identifies the origin of a bridged message (e.g. "irc",
+	// "xmpp"). Empty for native P2P messages.
+	Protocol string `json:"protocol,omitempty"`
+	// Room carries the external room/channel a bridged message came from,
+	// so the CLI can render a "[irc:#room/nick]" style prefix.
+	Room string `json:"room,omitempty"`
 }
 
 var (
 	peers []string
 	mu    sync.Mutex
+
+	myIdentity   *Identity
+	banList      *BanList
+	myListenPort string
+
+	activeBridges = map[string]Connector{} // protocol -> verbundener Connector
 )
 
 const reset = "\033[0m"
@@ -61,7 +75,23 @@ func initDB() *sql.DB {
 		CREATE TABLE IF NOT EXISTS users(
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			username TEXT UNIQUE NOT NULL,
-			password TEXT NOT NULL
+			password TEXT NOT NULL,
+			sign_pub BLOB,
+			kex_pub BLOB,
+			enc_priv BLOB,
+			priv_salt BLOB,
+			totp_secret BLOB,
+			totp_salt BLOB
+		);
+	`); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS recovery_codes(
+			username TEXT NOT NULL,
+			code_hash BLOB NOT NULL,
+			used INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY(username, code_hash)
 		);
 	`); err != nil {
 		log.Fatal(err)
@@ -74,22 +104,105 @@ func registerUser(db *sql.DB, username, password string) error {
 	if err != nil {
 		return err
 	}
-	_, err = db.Exec(`INSERT INTO users(username,password) VALUES(?,?)`, username, string(hash))
+	id, err := generateIdentity(username)
+	if err != nil {
+		return err
+	}
+	encPriv, salt, err := sealIdentity(id, password)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO users(username,password,sign_pub,kex_pub,enc_priv,priv_salt) VALUES(?,?,?,?,?,?)`,
+		username, string(hash), []byte(id.SignPub), id.KexPub[:], encPriv, salt)
 	return err
 }
 
-func loginUser(db *sql.DB, username, password string) bool {
+// loginUser prüft das Passwort und entschlüsselt bei Erfolg die
+// Langzeit-Identität (Signier- und Schlüsselaustausch-Schlüssel) des Users.
+func loginUser(db *sql.DB, username, password string) (*Identity, bool) {
 	var stored string
-	err := db.QueryRow(`SELECT password FROM users WHERE username = ?`, username).Scan(&stored)
+	var signPub, kexPub, encPriv, salt []byte
+	var totpSecretEnc, totpSalt []byte
+	err := db.QueryRow(`SELECT password, sign_pub, kex_pub, enc_priv, priv_salt, totp_secret, totp_salt FROM users WHERE username = ?`, username).
+		Scan(&stored, &signPub, &kexPub, &encPriv, &salt, &totpSecretEnc, &totpSalt)
 	if err != nil {
 		fmt.Println(cp("r", "❌ Benutzer nicht gefunden"))
-		return false
+		return nil, false
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
 		fmt.Println(cp("r", "❌ Falsches Passwort"))
+		return nil, false
+	}
+
+	if len(totpSecretEnc) > 0 {
+		if !check2FA(db, username, totpSecretEnc, totpSalt, password) {
+			return nil, false
+		}
+	}
+
+	var kexPubArr [32]byte
+	copy(kexPubArr[:], kexPub)
+	id, err := openIdentity(username, signPub, &kexPubArr, encPriv, salt, password)
+	if err != nil {
+		fmt.Println(cp("r", "❌ Identität konnte nicht entsperrt werden: ", err))
+		return nil, false
+	}
+	return id, true
+}
+
+// check2FA prompts for a TOTP code (or a recovery code as fallback) and
+// verifies it against the user's enrolled secret.
+func check2FA(db *sql.DB, username string, secretEnc, secretSalt []byte, password string) bool {
+	secret, err := openTOTPSecret(secretEnc, secretSalt, password)
+	if err != nil {
+		fmt.Println(cp("r", "❌ 2FA-Secret konnte nicht entsperrt werden: ", err))
+		return false
+	}
+	fmt.Print("2FA-Code (oder Recovery-Code): ")
+	reader := bufio.NewReader(os.Stdin)
+	code, _ := reader.ReadString('\n')
+	code = strings.TrimSpace(code)
+
+	if verifyTOTP(secret, code) {
+		return true
+	}
+	if consumeRecoveryCode(db, username, code) {
+		fmt.Println(cp("y", "⚠️  Recovery-Code verbraucht. Verbleibende Codes mit /recovery-codes prüfen."))
+		return true
+	}
+	fmt.Println(cp("r", "❌ Ungültiger 2FA- oder Recovery-Code"))
+	return false
+}
+
+func consumeRecoveryCode(db *sql.DB, username, code string) bool {
+	hash := hashRecoveryCode(code)
+	res, err := db.Exec(`UPDATE recovery_codes SET used = 1 WHERE username = ? AND code_hash = ? AND used = 0`, username, hash)
+	if err != nil {
+		return false
+	}
+	n, _ := res.RowsAffected()
+	return n > 0
+}
+
+// reauthenticate re-checks the user's password before a sensitive operation
+// like enrolling 2FA, since the plaintext password isn't kept around after
+// login.
+func reauthenticate(db *sql.DB, username, password string) bool {
+	var stored string
+	if err := db.QueryRow(`SELECT password FROM users WHERE username = ?`, username).Scan(&stored); err != nil {
 		return false
 	}
-	return true
+	return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+}
+
+func recoveryCodeStatus(db *sql.DB, username string) (remaining, total int, err error) {
+	if err = db.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE username = ?`, username).Scan(&total); err != nil {
+		return 0, 0, err
+	}
+	if err = db.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE username = ? AND used = 0`, username).Scan(&remaining); err != nil {
+		return 0, 0, err
+	}
+	return remaining, total, nil
 }
 
 func promptHidden(label string) string {
@@ -107,7 +220,7 @@ func promptHidden(label string) string {
 =========================== */
 
 func startServer(port string) {
-	ln, err := net.Listen("tcp", ":"+port)
+	ln, err := activeTransport.Listen(port)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -126,14 +239,90 @@ func startServer(port string) {
 
 func handleConnection(conn net.Conn) {
 	defer conn.Close()
+
+	// IP-Bans vor jeglichem Decode/Crypto-Aufwand prüfen, damit gebannte
+	// Adressen nicht einmal einen Handshake erzwingen können.
+	ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	if banned, reason := banList.IsBanned("", ip, ""); banned {
+		fmt.Println(cp("r", "Verbindung von ", ip, " abgelehnt: gebannt (", reason, ")"))
+		return
+	}
+
+	peerKex, peerSignPub, peerName, peerLastSeenSeq, peerListenPort, err := sendHello(conn, myIdentity, "")
+	if err != nil {
+		fmt.Println(cp("r", "Handshake fehlgeschlagen: ", err))
+		return
+	}
+
+	fingerprint := fingerprintOf(peerSignPub, peerKex[:])
+	if banned, reason := banList.IsBanned(peerName, ip, fingerprint); banned {
+		fmt.Println(cp("r", "Verbindung von ", peerName, " (", ip, ") abgelehnt: gebannt (", reason, ")"))
+		return
+	}
+
+	var peerAddrHint string
+	if peerListenPort != "" {
+		peerAddrHint = "tcp://" + net.JoinHostPort(ip, peerListenPort)
+		rememberPeerName(peerAddrHint, peerName)
+	}
+
+	// Nachrichten nachliefern, die peerName laut seinem last-seen-seq noch
+	// nicht gesehen hat, sowie alles, was für ihn/sie noch in unserer
+	// Pending-Outbox wartet (z.B. weil er/sie beim ursprünglichen Versand
+	// offline war und jetzt von sich aus wieder verbindet).
+	replayMissed(conn, peerName, peerKex, peerLastSeenSeq)
+	flushOutboxOverConn(conn, peerName, peerAddrHint, peerKex)
+
 	dec := json.NewDecoder(conn)
-	var msg Message
-	if err := dec.Decode(&msg); err == nil {
+	for {
+		var env sealedEnvelope
+		if err := dec.Decode(&env); err != nil {
+			return
+		}
+		msg, err := openMessage(env, myIdentity.KexPriv, &peerKex)
+		if err != nil {
+			fmt.Println(cp("r", "Nachricht von ", peerName, " konnte nicht entschlüsselt werden: ", err))
+			return
+		}
+		if _, err := appendMessage(peerName, msg); err != nil {
+			fmt.Println(cp("r", "Nachricht konnte nicht protokolliert werden: ", err))
+		}
 		// Eigene Nachrichten blau, fremde grün → hier einfach grün für Empfang
-		fmt.Printf("%s[%s]%s %s\n", colorMap["g"], msg.From, reset, msg.Content)
+		fmt.Printf("%s[%s]%s %s\n", colorMap["g"], messageLabel(msg), reset, msg.Content)
 	}
 }
 
+// replayMissed sends every logged message for peerName with a higher
+// sequence number than peerLastSeenSeq, so a reconnecting peer catches up on
+// what it missed while offline. Only the last 1000 logged entries are
+// considered; older ones are not replayed.
+func replayMissed(conn net.Conn, peerName string, peerKex [32]byte, peerLastSeenSeq int64) {
+	entries, err := history(peerName, 1000)
+	if err != nil {
+		return
+	}
+	enc := json.NewEncoder(conn)
+	for _, e := range entries {
+		if e.Seq <= peerLastSeenSeq {
+			continue
+		}
+		env, err := sealMessage(e.Msg, myIdentity.KexPriv, &peerKex)
+		if err != nil {
+			continue
+		}
+		_ = enc.Encode(env)
+	}
+}
+
+// messageLabel formats the sender prefix, rendering bridged messages as
+// "[irc:#room/nick]" instead of the plain "[nick]" used for native peers.
+func messageLabel(msg Message) string {
+	if msg.Protocol == "" {
+		return msg.From
+	}
+	return fmt.Sprintf("%s:%s/%s", msg.Protocol, msg.Room, msg.From)
+}
+
 func broadcast(msg Message) {
 	mu.Lock()
 	targets := append([]string(nil), peers...)
@@ -141,14 +330,31 @@ func broadcast(msg Message) {
 
 	for _, addr := range targets {
 		go func(a string) {
-			conn, err := net.Dial("tcp", a)
+			conn, err := dialPeer(a)
 			if err != nil {
 				fmt.Println(cp("r", "Failed to connect to ", a))
+				queueOutbox(a, msg)
 				return
 			}
 			defer conn.Close()
-			enc := json.NewEncoder(conn)
-			_ = enc.Encode(msg)
+
+			peerKex, _, name, _, _, err := sendHello(conn, myIdentity, a)
+			if err != nil {
+				fmt.Println(cp("r", "Handshake mit ", a, " fehlgeschlagen: ", err))
+				queueOutbox(a, msg)
+				return
+			}
+			rememberPeerName(a, name)
+
+			env, err := sealMessage(msg, myIdentity.KexPriv, &peerKex)
+			if err != nil {
+				fmt.Println(cp("r", "Verschlüsselung fehlgeschlagen: ", err))
+				return
+			}
+			_ = json.NewEncoder(conn).Encode(env)
+			if _, err := appendMessage(historyKey(a), msg); err != nil {
+				fmt.Println(cp("r", "Nachricht konnte nicht protokolliert werden: ", err))
+			}
 		}(addr)
 	}
 }
@@ -159,16 +365,39 @@ func broadcast(msg Message) {
 
 func main() {
 	fmt.Println(cp("g", "Welcome to the PostScriptum P2P Messenger!"))
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: go run . <port>")
+
+	adminFP := flag.String("admin", "", "Fingerprint, der beim Start Admin-Rechte bekommt")
+	useOnion := flag.Bool("onion", false, "über einen Tor Onion-Service statt rohem TCP lauschen/verbinden")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		fmt.Println("Usage: go run . [--admin <fingerprint>] [--onion] <port>")
 		return
 	}
-	port := os.Args[1]
+	port := flag.Arg(0)
+	myListenPort = port
+	if *adminFP != "" {
+		admins[*adminFP] = true
+	}
+	if *useOnion {
+		activeTransport = newOnionTransport("")
+	}
 
 	// DB & Auth
 	db := initDB()
 	defer db.Close()
 
+	var err error
+	banList, err = newBanList(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := initBridgeTable(db); err != nil {
+		log.Fatal(err)
+	}
+	if err := initStore(db); err != nil {
+		log.Fatal(err)
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 
 	var username string
@@ -192,8 +421,9 @@ func main() {
 			}
 			continue
 		case "l":
-			if loginUser(db, un, pw) {
+			if id, ok := loginUser(db, un, pw); ok {
 				username = un
+				myIdentity = id
 				fmt.Println(cp("g", "✅ Login erfolgreich!"))
 				goto START_CHAT
 			}
@@ -206,11 +436,27 @@ START_CHAT:
 	// Server starten
 	go startServer(port)
 
+	// Gespeicherte Bridge-Konten aus vorherigen Sessions wiederverbinden.
+	reconnectBridges(db, myIdentity)
+
 	// Hilfe anzeigen
 	fmt.Println(cp("c", "Commands:"))
-	fmt.Println(cp("c", "  /connect <ip:port>   - Peer hinzufügen"))
-	fmt.Println(cp("c", "  /peers               - Peerliste anzeigen"))
-	fmt.Println(cp("c", "  /quit                - Beenden"))
+	fmt.Println(cp("c", "  /connect <ip:port>          - Peer hinzufügen"))
+	fmt.Println(cp("c", "  /peers                      - Peerliste anzeigen"))
+	fmt.Println(cp("c", "  /fingerprint                - eigenen Fingerprint anzeigen"))
+	fmt.Println(cp("c", "  /trust <peer> <fingerprint> - Fingerprint eines Peers pinnen"))
+	fmt.Println(cp("c", "  /ban <type> <value> [dur]   - Peer bannen (admin)"))
+	fmt.Println(cp("c", "  /unban <type> <value>       - Ban aufheben (admin)"))
+	fmt.Println(cp("c", "  /banned                     - Banliste anzeigen (admin)"))
+	fmt.Println(cp("c", "  /whitelist <fingerprint>    - Fingerprint von Bans ausnehmen (admin)"))
+	fmt.Println(cp("c", "  /bridge add <proto> <server> <nick> - externes Konto verbinden"))
+	fmt.Println(cp("c", "  /bridge join <proto> <room> - Bridge-Raum beitreten"))
+	fmt.Println(cp("c", "  /bridge list                - verbundene Bridges anzeigen"))
+	fmt.Println(cp("c", "  /history <peer> [n]         - letzte N Nachrichten anzeigen"))
+	fmt.Println(cp("c", "  /search <term>              - Volltextsuche über den Verlauf"))
+	fmt.Println(cp("c", "  /enable2fa                  - TOTP-2FA für diesen Account aktivieren"))
+	fmt.Println(cp("c", "  /recovery-codes             - Status der Recovery-Codes anzeigen"))
+	fmt.Println(cp("c", "  /quit                       - Beenden"))
 
 	sc := bufio.NewScanner(os.Stdin)
 	for sc.Scan() {
@@ -236,13 +482,273 @@ START_CHAT:
 		case strings.HasPrefix(line, "/connect "):
 			peer := strings.TrimSpace(strings.TrimPrefix(line, "/connect "))
 			if peer == "" {
-				fmt.Println(cp("y", "Usage: /connect <ip:port>"))
+				fmt.Println(cp("y", "Usage: /connect <ip:port|addr.onion:port>"))
 				continue
 			}
+			if _, hp := splitTransportAddr(peer); !strings.Contains(peer, "://") {
+				scheme := "tcp"
+				if strings.HasSuffix(strings.Split(hp, ":")[0], ".onion") {
+					scheme = "onion"
+				}
+				peer = scheme + "://" + hp
+			}
 			mu.Lock()
 			peers = append(peers, peer)
 			mu.Unlock()
 			fmt.Println(cp("g", "Connected to ", peer))
+			go flushOutbox(peer)
+			continue
+
+		case strings.HasPrefix(line, "/history "):
+			args := strings.Fields(strings.TrimPrefix(line, "/history "))
+			if len(args) < 1 {
+				fmt.Println(cp("y", "Usage: /history <peer> [n]"))
+				continue
+			}
+			n := 20
+			if len(args) > 1 {
+				if parsed, err := strconv.Atoi(args[1]); err == nil {
+					n = parsed
+				}
+			}
+			entries, err := history(historyKey(args[0]), n)
+			if err != nil {
+				fmt.Println(cp("r", "Verlauf konnte nicht geladen werden: ", err))
+				continue
+			}
+			for _, e := range entries {
+				fmt.Println(cp("w", formatHistoryLine(e)))
+			}
+			continue
+
+		case strings.HasPrefix(line, "/search "):
+			term := strings.TrimSpace(strings.TrimPrefix(line, "/search "))
+			if term == "" {
+				fmt.Println(cp("y", "Usage: /search <term>"))
+				continue
+			}
+			results, err := search(term)
+			if err != nil {
+				fmt.Println(cp("r", "Suche fehlgeschlagen: ", err))
+				continue
+			}
+			for _, e := range results {
+				fmt.Println(cp("w", e.Peer, " ", formatHistoryLine(e)))
+			}
+			continue
+
+		case line == "/enable2fa":
+			pw := promptHidden("Passwort zur Bestätigung: ")
+			if !reauthenticate(db, username, pw) {
+				fmt.Println(cp("r", "❌ Falsches Passwort"))
+				continue
+			}
+			secret, err := generateTOTPSecret()
+			if err != nil {
+				fmt.Println(cp("r", "2FA konnte nicht eingerichtet werden: ", err))
+				continue
+			}
+			encSecret, salt, err := sealTOTPSecret(secret, pw)
+			if err != nil {
+				fmt.Println(cp("r", "2FA konnte nicht eingerichtet werden: ", err))
+				continue
+			}
+			if _, err := db.Exec(`UPDATE users SET totp_secret = ?, totp_salt = ? WHERE username = ?`, encSecret, salt, username); err != nil {
+				fmt.Println(cp("r", "2FA konnte nicht gespeichert werden: ", err))
+				continue
+			}
+			printTOTPEnrollment(username, secret)
+
+			codes, hashes, err := generateRecoveryCodes()
+			if err != nil {
+				fmt.Println(cp("r", "Recovery-Codes konnten nicht erzeugt werden: ", err))
+				continue
+			}
+			if _, err := db.Exec(`DELETE FROM recovery_codes WHERE username = ?`, username); err != nil {
+				fmt.Println(cp("r", "Recovery-Codes konnten nicht gespeichert werden: ", err))
+				continue
+			}
+			for _, h := range hashes {
+				if _, err := db.Exec(`INSERT INTO recovery_codes(username, code_hash) VALUES(?,?)`, username, h); err != nil {
+					fmt.Println(cp("r", "Recovery-Codes konnten nicht gespeichert werden: ", err))
+					continue
+				}
+			}
+			fmt.Println(cp("c", "Recovery-Codes (jeder nur einmal verwendbar, jetzt sichern):"))
+			for _, c := range codes {
+				fmt.Println(cp("w", "  ", c))
+			}
+			fmt.Println(cp("g", "✅ 2FA aktiviert."))
+			continue
+
+		case line == "/recovery-codes":
+			remaining, total, err := recoveryCodeStatus(db, username)
+			if err != nil {
+				fmt.Println(cp("r", "Status konnte nicht geladen werden: ", err))
+				continue
+			}
+			fmt.Println(cp("c", remaining, " von ", total, " Recovery-Codes noch übrig. Codes werden nur einmal bei /enable2fa angezeigt."))
+			continue
+
+		case line == "/fingerprint":
+			printFingerprint(myIdentity)
+			continue
+
+		case strings.HasPrefix(line, "/trust "):
+			args := strings.Fields(strings.TrimPrefix(line, "/trust "))
+			if len(args) != 2 {
+				fmt.Println(cp("y", "Usage: /trust <peer> <fingerprint>"))
+				continue
+			}
+			pinTrust(args[0], args[1])
+			fmt.Println(cp("g", "Fingerprint für ", args[0], " gepinnt."))
+			continue
+
+		case strings.HasPrefix(line, "/ban "):
+			if !isAdmin(myIdentity.Fingerprint()) {
+				fmt.Println(cp("r", "permission denied"))
+				continue
+			}
+			args := strings.Fields(strings.TrimPrefix(line, "/ban "))
+			if len(args) < 2 {
+				fmt.Println(cp("y", "Usage: /ban <type> <value> [duration]"))
+				continue
+			}
+			typ, err := parseBanType(args[0])
+			if err != nil {
+				fmt.Println(cp("r", err))
+				continue
+			}
+			var durStr string
+			if len(args) > 2 {
+				durStr = args[2]
+			}
+			dur, err := parseDuration(durStr)
+			if err != nil {
+				fmt.Println(cp("r", "Ungültige Dauer: ", err))
+				continue
+			}
+			if err := banList.Ban(typ, args[1], dur); err != nil {
+				fmt.Println(cp("r", "Ban fehlgeschlagen: ", err))
+				continue
+			}
+			fmt.Println(cp("g", args[1], " gebannt."))
+			continue
+
+		case strings.HasPrefix(line, "/unban "):
+			if !isAdmin(myIdentity.Fingerprint()) {
+				fmt.Println(cp("r", "permission denied"))
+				continue
+			}
+			args := strings.Fields(strings.TrimPrefix(line, "/unban "))
+			if len(args) != 2 {
+				fmt.Println(cp("y", "Usage: /unban <type> <value>"))
+				continue
+			}
+			typ, err := parseBanType(args[0])
+			if err != nil {
+				fmt.Println(cp("r", err))
+				continue
+			}
+			if err := banList.Unban(typ, args[1]); err != nil {
+				fmt.Println(cp("r", "Unban fehlgeschlagen: ", err))
+				continue
+			}
+			fmt.Println(cp("g", args[1], " entbannt."))
+			continue
+
+		case line == "/banned":
+			if !isAdmin(myIdentity.Fingerprint()) {
+				fmt.Println(cp("r", "permission denied"))
+				continue
+			}
+			for _, e := range banList.List() {
+				until := "permanent"
+				if !e.Until.IsZero() {
+					until = e.Until.Format("2006-01-02 15:04:05")
+				}
+				fmt.Println(cp("w", "• ", e.Type, " ", e.Value, " (bis ", until, ")"))
+			}
+			continue
+
+		case strings.HasPrefix(line, "/whitelist "):
+			if !isAdmin(myIdentity.Fingerprint()) {
+				fmt.Println(cp("r", "permission denied"))
+				continue
+			}
+			fp := strings.TrimSpace(strings.TrimPrefix(line, "/whitelist "))
+			if fp == "" {
+				fmt.Println(cp("y", "Usage: /whitelist <fingerprint>"))
+				continue
+			}
+			if err := banList.Whitelist(fp); err != nil {
+				fmt.Println(cp("r", "Whitelist fehlgeschlagen: ", err))
+				continue
+			}
+			fmt.Println(cp("g", fp, " auf die Whitelist gesetzt."))
+			continue
+
+		case strings.HasPrefix(line, "/bridge add "):
+			args := strings.Fields(strings.TrimPrefix(line, "/bridge add "))
+			if len(args) != 3 {
+				fmt.Println(cp("y", "Usage: /bridge add <proto> <server> <nick>"))
+				continue
+			}
+			proto, server, nick := args[0], args[1], args[2]
+			conn, err := newConnector(proto)
+			if err != nil {
+				fmt.Println(cp("r", err))
+				continue
+			}
+			pw := promptHidden(fmt.Sprintf("Passwort für %s auf %s: ", nick, server))
+			acc := Account{Protocol: proto, Server: server, Nick: nick, Password: pw}
+			// OnMessage muss gesetzt sein, bevor Connect den Read-Loop
+			// startet: der Callback wird ohne Lock gelesen/geschrieben, also
+			// reicht nur die Reihenfolge (vor dem go-Statement in Connect)
+			// als Synchronisationspunkt.
+			conn.OnMessage(bridgeToBroadcast)
+			if err := conn.Connect(acc); err != nil {
+				fmt.Println(cp("r", "Bridge-Verbindung fehlgeschlagen: ", err))
+				continue
+			}
+			if err := saveBridgeAccount(db, myIdentity, acc); err != nil {
+				fmt.Println(cp("r", "Bridge-Konto konnte nicht gespeichert werden: ", err))
+				continue
+			}
+			activeBridges[proto] = conn
+			fmt.Println(cp("g", "Bridge ", proto, " hinzugefügt."))
+			continue
+
+		case strings.HasPrefix(line, "/bridge join "):
+			args := strings.Fields(strings.TrimPrefix(line, "/bridge join "))
+			if len(args) != 2 {
+				fmt.Println(cp("y", "Usage: /bridge join <proto> <room>"))
+				continue
+			}
+			proto, room := args[0], args[1]
+			conn, ok := activeBridges[proto]
+			if !ok {
+				fmt.Println(cp("r", "Keine aktive Bridge für ", proto, " (erst /bridge add)"))
+				continue
+			}
+			if err := conn.Join(room); err != nil {
+				fmt.Println(cp("r", "Join fehlgeschlagen: ", err))
+				continue
+			}
+			continue
+
+		case line == "/bridge list":
+			accs, err := listBridgeAccounts(db, myIdentity.Username)
+			if err != nil {
+				fmt.Println(cp("r", "Bridge-Liste konnte nicht geladen werden: ", err))
+				continue
+			}
+			if len(accs) == 0 {
+				fmt.Println(cp("y", "(keine Bridges)"))
+			}
+			for _, a := range accs {
+				fmt.Println(cp("w", "• ", a.Protocol, " ", a.Nick, "@", a.Server))
+			}
 			continue
 		}
 
@@ -255,6 +761,7 @@ START_CHAT:
 
 		msg := Message{From: username, Content: line}
 		broadcast(msg)
+		bridgeOutgoing(msg)
 	}
 
 	if err := sc.Err(); err != nil {
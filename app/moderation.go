@@ -0,0 +1,242 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/* ===========================
+   Moderation: Bans & Whitelist
+=========================== */
+
+// BanType legt fest, wonach ein Ban-Eintrag greift.
+type BanType string
+
+const (
+	BanUsername    BanType = "username"
+	BanIP          BanType = "ip"
+	BanFingerprint BanType = "fingerprint"
+)
+
+// BanReason ist ein typisierter Grund, damit Audit-Logs strukturiert bleiben
+// statt freier Strings.
+type BanReason string
+
+const (
+	ReasonManual   BanReason = "manual"
+	ReasonExpired  BanReason = "expired"
+	ReasonNotFound BanReason = "not_found"
+)
+
+type banEntry struct {
+	Type   BanType
+	Value  string
+	Until  time.Time // zero = permanent
+	Reason BanReason
+}
+
+// BanList verwaltet Bans in SQLite und hält einen In-Memory-Cache mit
+// TTL-Ablauf, damit handleConnection nicht bei jeder Verbindung die DB
+// treffen muss.
+type BanList struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	cache map[string]banEntry // key = string(Type)+":"+Value
+
+	whitelist map[string]bool // gepinnte Fingerprints, die nie gebannt werden
+}
+
+func newBanList(db *sql.DB) (*BanList, error) {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS bans(
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			type TEXT NOT NULL,
+			value TEXT NOT NULL,
+			until INTEGER NOT NULL, -- unix seconds, 0 = permanent
+			reason TEXT NOT NULL
+		);
+	`); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS whitelist(
+			fingerprint TEXT PRIMARY KEY
+		);
+	`); err != nil {
+		return nil, err
+	}
+	bl := &BanList{db: db, cache: map[string]banEntry{}, whitelist: map[string]bool{}}
+	if err := bl.reload(); err != nil {
+		return nil, err
+	}
+	return bl, nil
+}
+
+func banKey(t BanType, value string) string { return string(t) + ":" + value }
+
+func (bl *BanList) reload() error {
+	rows, err := bl.db.Query(`SELECT type, value, until, reason FROM bans`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	bl.cache = map[string]banEntry{}
+	for rows.Next() {
+		var typ, value, reason string
+		var until int64
+		if err := rows.Scan(&typ, &value, &until, &reason); err != nil {
+			return err
+		}
+		entry := banEntry{Type: BanType(typ), Value: value, Reason: BanReason(reason)}
+		if until > 0 {
+			entry.Until = time.Unix(until, 0)
+		}
+		bl.cache[banKey(entry.Type, entry.Value)] = entry
+	}
+
+	wRows, err := bl.db.Query(`SELECT fingerprint FROM whitelist`)
+	if err != nil {
+		return err
+	}
+	defer wRows.Close()
+	for wRows.Next() {
+		var fp string
+		if err := wRows.Scan(&fp); err != nil {
+			return err
+		}
+		bl.whitelist[fp] = true
+	}
+	return nil
+}
+
+// Ban persistiert einen Ban und aktualisiert den Cache. duration == 0 bedeutet
+// permanent.
+func (bl *BanList) Ban(t BanType, value string, duration time.Duration) error {
+	var until int64
+	if duration > 0 {
+		// Auf die nächste volle Sekunde aufrunden statt abzuschneiden, damit
+		// ein Ban unter 1s nicht schon beim nächsten IsBanned-Aufruf als
+		// abgelaufen gilt.
+		expiry := time.Now().Add(duration)
+		until = expiry.Unix()
+		if expiry.Nanosecond() > 0 {
+			until++
+		}
+	}
+	if _, err := bl.db.Exec(`INSERT INTO bans(type,value,until,reason) VALUES(?,?,?,?)`,
+		string(t), value, until, string(ReasonManual)); err != nil {
+		return err
+	}
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	entry := banEntry{Type: t, Value: value, Reason: ReasonManual}
+	if until > 0 {
+		entry.Until = time.Unix(until, 0)
+	}
+	bl.cache[banKey(t, value)] = entry
+	return nil
+}
+
+func (bl *BanList) Unban(t BanType, value string) error {
+	if _, err := bl.db.Exec(`DELETE FROM bans WHERE type = ? AND value = ?`, string(t), value); err != nil {
+		return err
+	}
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	delete(bl.cache, banKey(t, value))
+	return nil
+}
+
+func (bl *BanList) Whitelist(fingerprint string) error {
+	if _, err := bl.db.Exec(`INSERT OR IGNORE INTO whitelist(fingerprint) VALUES(?)`, fingerprint); err != nil {
+		return err
+	}
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	bl.whitelist[fingerprint] = true
+	return nil
+}
+
+// IsBanned prüft Username, IP und Fingerprint gegen den Cache und räumt
+// abgelaufene Einträge lazy auf. Gibt den typisierten Grund zurück, falls
+// gebannt, damit der Aufrufer strukturierte Audit-Events loggen kann.
+func (bl *BanList) IsBanned(username, ip, fingerprint string) (bool, BanReason) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	if bl.whitelist[fingerprint] {
+		return false, ""
+	}
+
+	checks := []struct {
+		typ   BanType
+		value string
+	}{
+		{BanUsername, username},
+		{BanIP, ip},
+		{BanFingerprint, fingerprint},
+	}
+	for _, c := range checks {
+		if c.value == "" {
+			continue
+		}
+		entry, ok := bl.cache[banKey(c.typ, c.value)]
+		if !ok {
+			continue
+		}
+		if !entry.Until.IsZero() && time.Now().After(entry.Until) {
+			delete(bl.cache, banKey(c.typ, c.value))
+			continue
+		}
+		return true, entry.Reason
+	}
+	return false, ReasonNotFound
+}
+
+func (bl *BanList) List() []banEntry {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	out := make([]banEntry, 0, len(bl.cache))
+	for _, e := range bl.cache {
+		out = append(out, e)
+	}
+	return out
+}
+
+func parseBanType(s string) (BanType, error) {
+	switch strings.ToLower(s) {
+	case string(BanUsername):
+		return BanUsername, nil
+	case string(BanIP):
+		return BanIP, nil
+	case string(BanFingerprint):
+		return BanFingerprint, nil
+	default:
+		return "", fmt.Errorf("unbekannter Ban-Typ %q (erwartet: username, ip, fingerprint)", s)
+	}
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if secs, err := strconv.Atoi(s); err == nil {
+		return time.Duration(secs) * time.Second, nil
+	}
+	return time.ParseDuration(s)
+}
+
+/* ===========================
+   Admin-Rechte
+=========================== */
+
+var admins = map[string]bool{} // Fingerprint -> ist Admin
+
+func isAdmin(fingerprint string) bool { return admins[fingerprint] }
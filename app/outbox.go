@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+)
+
+/* ===========================
+   Pending-Outbox (Retry bei Offline-Peers)
+=========================== */
+
+var (
+	outboxMu sync.Mutex
+	outbox   = map[string][]Message{} // Peer-Adresse -> wartende Nachrichten
+
+	peerNameMu sync.Mutex
+	peerName   = map[string]string{} // Peer-Adresse -> Username, gelernt beim Handshake
+)
+
+func rememberPeerName(addr, name string) {
+	peerNameMu.Lock()
+	defer peerNameMu.Unlock()
+	peerName[addr] = name
+}
+
+// historyKey resolves the store/log key for a peer address: the username
+// once we've handshaked with it, falling back to the raw address.
+func historyKey(addr string) string {
+	peerNameMu.Lock()
+	defer peerNameMu.Unlock()
+	if name, ok := peerName[addr]; ok {
+		return name
+	}
+	return addr
+}
+
+func queueOutbox(addr string, msg Message) {
+	key := historyKey(addr)
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+	outbox[key] = append(outbox[key], msg)
+}
+
+// popOutbox removes and returns everything queued under key.
+func popOutbox(key string) []Message {
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+	pending := outbox[key]
+	delete(outbox, key)
+	return pending
+}
+
+// flushOutbox retries every message queued for addr, in order, over a
+// single fresh connection. Called after a successful /connect so messages
+// sent while a peer was offline are delivered once they come back.
+func flushOutbox(addr string) {
+	key := historyKey(addr)
+	pending := popOutbox(key)
+	if len(pending) == 0 {
+		return
+	}
+
+	conn, err := dialPeer(addr)
+	if err != nil {
+		fmt.Println(cp("r", "Retry an ", addr, " fehlgeschlagen: ", err))
+		outboxMu.Lock()
+		outbox[key] = append(pending, outbox[key]...)
+		outboxMu.Unlock()
+		return
+	}
+	defer conn.Close()
+
+	peerKex, _, name, _, _, err := sendHello(conn, myIdentity, addr)
+	if err != nil {
+		fmt.Println(cp("r", "Handshake mit ", addr, " fehlgeschlagen: ", err))
+		return
+	}
+	rememberPeerName(addr, name)
+
+	enc := json.NewEncoder(conn)
+	for _, msg := range pending {
+		env, err := sealMessage(msg, myIdentity.KexPriv, &peerKex)
+		if err != nil {
+			continue
+		}
+		_ = enc.Encode(env)
+		_, _ = appendMessage(historyKey(addr), msg)
+	}
+	fmt.Println(cp("g", len(pending), " nachgeholte Nachricht(en) an ", addr, " zugestellt."))
+}
+
+// flushOutboxOverConn delivers any messages queued for peerName (or for the
+// address we just learned it listens on) over an already-open connection —
+// used when that peer is the one reconnecting to us, so messages queued
+// while they were offline don't have to wait for them to be /connect'ed to
+// again from our side.
+func flushOutboxOverConn(conn net.Conn, peerName string, peerAddrHint string, peerKex [32]byte) {
+	pending := popOutbox(peerName)
+	if peerAddrHint != "" && peerAddrHint != peerName {
+		pending = append(pending, popOutbox(peerAddrHint)...)
+	}
+	if len(pending) == 0 {
+		return
+	}
+	enc := json.NewEncoder(conn)
+	for _, msg := range pending {
+		env, err := sealMessage(msg, myIdentity.KexPriv, &peerKex)
+		if err != nil {
+			continue
+		}
+		_ = enc.Encode(env)
+		_, _ = appendMessage(peerName, msg)
+	}
+	fmt.Println(cp("g", len(pending), " nachgeholte Nachricht(en) an ", peerName, " zugestellt."))
+}
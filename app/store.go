@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/* ===========================
+   Nachrichtenverlauf (append-only)
+=========================== */
+
+// storedMessage is one entry of a peer's history: a Message plus the
+// bookkeeping needed for ordering, replay and integrity checking.
+type storedMessage struct {
+	Peer string  `json:"peer"`
+	Seq  int64   `json:"seq"`
+	Ts   int64   `json:"ts"`
+	Hash string  `json:"hash"`
+	Msg  Message `json:"msg"`
+}
+
+var (
+	storeDB *sql.DB
+
+	seqMu  sync.Mutex
+	seqNum = map[string]int64{} // peer -> höchste vergebene Seq, gecacht aus der DB
+)
+
+func initStore(db *sql.DB) error {
+	storeDB = db
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS message_log(
+			peer TEXT NOT NULL,
+			seq INTEGER NOT NULL,
+			ts INTEGER NOT NULL,
+			from_user TEXT NOT NULL,
+			content TEXT NOT NULL,
+			hash TEXT NOT NULL,
+			PRIMARY KEY(peer, seq)
+		);
+		CREATE VIRTUAL TABLE IF NOT EXISTS message_log_fts USING fts5(
+			content, peer UNINDEXED, from_user UNINDEXED, content='message_log', content_rowid='rowid'
+		);
+	`)
+	if err != nil {
+		return err
+	}
+	return loadSeqCache(db)
+}
+
+func loadSeqCache(db *sql.DB) error {
+	rows, err := db.Query(`SELECT peer, MAX(seq) FROM message_log GROUP BY peer`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	seqMu.Lock()
+	defer seqMu.Unlock()
+	for rows.Next() {
+		var peer string
+		var seq int64
+		if err := rows.Scan(&peer, &seq); err != nil {
+			return err
+		}
+		seqNum[peer] = seq
+	}
+	return nil
+}
+
+func nextSeq(peer string) int64 {
+	seqMu.Lock()
+	defer seqMu.Unlock()
+	seqNum[peer]++
+	return seqNum[peer]
+}
+
+func lastSeenSeq(peer string) int64 {
+	seqMu.Lock()
+	defer seqMu.Unlock()
+	return seqNum[peer]
+}
+
+func logDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".postscriptum", "logs")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func logFilePath(peer string) (string, error) {
+	dir, err := logDir()
+	if err != nil {
+		return "", err
+	}
+	safe := hex.EncodeToString([]byte(peer))
+	return filepath.Join(dir, safe+".jsonl"), nil
+}
+
+// appendMessage assigns the next sequence number for peer, appends the
+// entry to that peer's JSONL segment and indexes it in SQLite (+FTS5) so
+// /history and /search can find it later.
+func appendMessage(peer string, msg Message) (storedMessage, error) {
+	seq := nextSeq(peer)
+	sum := sha256.Sum256([]byte(msg.From + msg.Content))
+	entry := storedMessage{
+		Peer: peer,
+		Seq:  seq,
+		Ts:   time.Now().Unix(),
+		Hash: hex.EncodeToString(sum[:]),
+		Msg:  msg,
+	}
+
+	path, err := logFilePath(peer)
+	if err != nil {
+		return entry, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return entry, err
+	}
+	defer f.Close()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return entry, err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return entry, err
+	}
+
+	tx, err := storeDB.Begin()
+	if err != nil {
+		return entry, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO message_log(peer,seq,ts,from_user,content,hash) VALUES(?,?,?,?,?,?)`,
+		entry.Peer, entry.Seq, entry.Ts, msg.From, msg.Content, entry.Hash)
+	if err != nil {
+		return entry, err
+	}
+	rowid, err := res.LastInsertId()
+	if err != nil {
+		return entry, err
+	}
+	// message_log_fts is an external-content FTS5 table: it is not kept in
+	// sync automatically, so every insert into message_log needs a matching
+	// insert here (same rowid) or /search silently returns nothing.
+	if _, err := tx.Exec(`INSERT INTO message_log_fts(rowid, content, peer, from_user) VALUES(?,?,?,?)`,
+		rowid, msg.Content, entry.Peer, msg.From); err != nil {
+		return entry, err
+	}
+	return entry, tx.Commit()
+}
+
+// history returns the last n entries for peer, oldest first.
+func history(peer string, n int) ([]storedMessage, error) {
+	rows, err := storeDB.Query(`
+		SELECT seq, ts, from_user, content, hash FROM message_log
+		WHERE peer = ? ORDER BY seq DESC LIMIT ?`, peer, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []storedMessage
+	for rows.Next() {
+		var e storedMessage
+		e.Peer = peer
+		if err := rows.Scan(&e.Seq, &e.Ts, &e.Msg.From, &e.Msg.Content, &e.Hash); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+// search runs a full-text search over every logged message via FTS5.
+func search(term string) ([]storedMessage, error) {
+	rows, err := storeDB.Query(`
+		SELECT m.peer, m.seq, m.ts, m.from_user, m.content, m.hash
+		FROM message_log_fts f JOIN message_log m ON m.rowid = f.rowid
+		WHERE message_log_fts MATCH ?
+		ORDER BY m.ts DESC LIMIT 50`, term)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []storedMessage
+	for rows.Next() {
+		var e storedMessage
+		if err := rows.Scan(&e.Peer, &e.Seq, &e.Ts, &e.Msg.From, &e.Msg.Content, &e.Hash); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func formatHistoryLine(e storedMessage) string {
+	ts := time.Unix(e.Ts, 0).Format("2006-01-02 15:04")
+	return fmt.Sprintf("[%s] %s: %s", ts, e.Msg.From, e.Msg.Content)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mdp/qrterminal/v3"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+/* ===========================
+   TOTP Zwei-Faktor-Auth (RFC 6238)
+=========================== */
+
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpWindow = 1 // +/- ein Zeitschritt Toleranz
+)
+
+func generateTOTPSecret() ([]byte, error) {
+	secret := make([]byte, 20) // 160 bit, wie bei Google Authenticator üblich
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+func totpBase32(secret []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+}
+
+func totpURL(username string, secret []byte) string {
+	v := url.Values{}
+	v.Set("secret", totpBase32(secret))
+	v.Set("issuer", "PostScriptum")
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprintf("%d", totpDigits))
+	v.Set("period", fmt.Sprintf("%d", totpPeriod))
+	label := url.PathEscape(fmt.Sprintf("PostScriptum:%s", username))
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, v.Encode())
+}
+
+func printTOTPEnrollment(username string, secret []byte) {
+	u := totpURL(username, secret)
+	fmt.Println(cp("c", "TOTP-Secret: ", totpBase32(secret)))
+	fmt.Println(cp("c", u))
+	qrterminal.GenerateHalfBlock(u, qrterminal.L, os.Stdout)
+}
+
+func totpCodeAt(secret []byte, t time.Time) string {
+	counter := uint64(t.Unix() / totpPeriod)
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], counter)
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(buf[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := (uint32(sum[offset])&0x7f)<<24 |
+		uint32(sum[offset+1])<<16 |
+		uint32(sum[offset+2])<<8 |
+		uint32(sum[offset+3])
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, code%mod)
+}
+
+// verifyTOTP accepts a code generated up to totpWindow steps before or after
+// now, to tolerate clock drift between client and server.
+func verifyTOTP(secret []byte, code string) bool {
+	now := time.Now()
+	for i := -totpWindow; i <= totpWindow; i++ {
+		want := totpCodeAt(secret, now.Add(time.Duration(i)*totpPeriod*time.Second))
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// sealTOTPSecret/openTOTPSecret protect the raw TOTP secret at rest with a
+// key derived from the user's login password (same argon2id+secretbox
+// construction as the identity keys), so a DB leak alone doesn't defeat 2FA.
+func sealTOTPSecret(secret []byte, password string) (ciphertext, salt []byte, err error) {
+	salt = make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	key := argon2Key(password, salt)
+
+	var nonce [24]byte
+	if _, err = rand.Read(nonce[:]); err != nil {
+		return nil, nil, err
+	}
+	return secretbox.Seal(nonce[:], secret, &nonce, key), salt, nil
+}
+
+func openTOTPSecret(ciphertext, salt []byte, password string) ([]byte, error) {
+	if len(ciphertext) < 24 {
+		return nil, errors.New("totp: ciphertext too short")
+	}
+	key := argon2Key(password, salt)
+	var nonce [24]byte
+	copy(nonce[:], ciphertext[:24])
+	secret, ok := secretbox.Open(nil, ciphertext[24:], &nonce, key)
+	if !ok {
+		return nil, errors.New("totp: falsches Passwort oder beschädigte Daten")
+	}
+	return secret, nil
+}
+
+/* ===========================
+   Recovery Codes
+=========================== */
+
+// generateRecoveryCodes returns 10 single-use recovery codes in plaintext
+// (to show the user once) plus their SHA-256 hashes (to persist).
+func generateRecoveryCodes() (plain []string, hashes [][]byte, err error) {
+	for i := 0; i < 10; i++ {
+		raw := make([]byte, 5)
+		if _, err = rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToUpper(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw))
+		sum := sha256.Sum256([]byte(code))
+		plain = append(plain, code)
+		hashes = append(hashes, sum[:])
+	}
+	return plain, hashes, nil
+}
+
+func hashRecoveryCode(code string) []byte {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(code))))
+	return sum[:]
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+/* ===========================
+   Pluggable Transport
+=========================== */
+
+// Transport abstracts how a Listen/Dial pair actually moves bytes, so
+// startServer/broadcast can run over plain TCP or over a metadata-resistant
+// onion service without caring which.
+type Transport interface {
+	Listen(port string) (net.Listener, error)
+	Dial(addr string) (net.Conn, error)
+	// Scheme is the address tag this transport advertises in the peer list
+	// (e.g. "tcp", "onion").
+	Scheme() string
+}
+
+type tcpTransport struct{}
+
+func (tcpTransport) Listen(port string) (net.Listener, error) { return net.Listen("tcp", ":"+port) }
+func (tcpTransport) Dial(addr string) (net.Conn, error)       { return net.Dial("tcp", addr) }
+func (tcpTransport) Scheme() string                           { return "tcp" }
+
+// activeTransport is the transport startServer listens on. Defaults to plain
+// TCP; --onion swaps in onionTransport. Outgoing connections go through
+// dialPeer, which picks the transport from each peer address's scheme.
+var activeTransport Transport = tcpTransport{}
+
+// splitTransportAddr splits a tagged peer address like "onion://abc.onion:9001"
+// or a bare "host:port" (assumed tcp) into (scheme, host:port).
+func splitTransportAddr(addr string) (scheme, hostport string) {
+	if idx := strings.Index(addr, "://"); idx >= 0 {
+		return addr[:idx], addr[idx+3:]
+	}
+	return "tcp", addr
+}
+
+func dialPeer(addr string) (net.Conn, error) {
+	scheme, hostport := splitTransportAddr(addr)
+	switch scheme {
+	case "onion":
+		return dialOnion(hostport)
+	case "tcp":
+		return tcpTransport{}.Dial(hostport)
+	default:
+		return nil, fmt.Errorf("transport: unbekanntes Schema %q", scheme)
+	}
+}
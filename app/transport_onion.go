@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/net/proxy"
+)
+
+/* ===========================
+   Onion-Service-Transport (Tor)
+=========================== */
+
+const onionKeyFile = "onion_v3.key"
+
+// onionTransport runs the listener as a Tor v3 onion service and dials out
+// through the local Tor SOCKS proxy, so PostScriptum never needs a public
+// IP and the peer list stays metadata-resistant (ssh-chat over Cwtch-style
+// hidden services).
+type onionTransport struct {
+	socksAddr string // e.g. "127.0.0.1:9050"
+}
+
+func newOnionTransport(socksAddr string) *onionTransport {
+	if socksAddr == "" {
+		socksAddr = "127.0.0.1:9050"
+	}
+	return &onionTransport{socksAddr: socksAddr}
+}
+
+func (o *onionTransport) Scheme() string { return "onion" }
+
+// Listen publishes a v3 onion service for the given port via the local Tor
+// control connection (github.com/cretz/bine) and returns a listener that
+// receives the service's incoming connections.
+func (o *onionTransport) Listen(port string) (net.Listener, error) {
+	key, err := loadOrCreateOnionKey()
+	if err != nil {
+		return nil, err
+	}
+	return startOnionService(key, port)
+}
+
+// Dial connects to a .onion:port address through the local Tor SOCKS proxy.
+func (o *onionTransport) Dial(addr string) (net.Conn, error) {
+	dialer, err := proxy.SOCKS5("tcp", o.socksAddr, nil, proxy.Direct)
+	if err != nil {
+		return nil, err
+	}
+	return dialer.Dial("tcp", addr)
+}
+
+func dialOnion(addr string) (net.Conn, error) {
+	return newOnionTransport("").Dial(addr)
+}
+
+func onionDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".postscriptum", "onion")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// loadOrCreateOnionKey persists the ed25519 v3 onion key under
+// ~/.postscriptum/onion/ so the service address stays stable across
+// restarts.
+func loadOrCreateOnionKey() (ed25519.PrivateKey, error) {
+	dir, err := onionDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, onionKeyFile)
+
+	if data, err := os.ReadFile(path); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil || len(block.Bytes) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("onion: beschädigter Schlüssel in %s", path)
+		}
+		return ed25519.PrivateKey(block.Bytes), nil
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	block := &pem.Block{Type: "POSTSCRIPTUM ONION V3 KEY", Bytes: priv}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
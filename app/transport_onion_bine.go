@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/cretz/bine/tor"
+)
+
+// startOnionService starts a local tor process via bine and publishes a
+// v3 onion service for the given local port, using the persisted ed25519
+// key so the .onion address is stable across restarts.
+func startOnionService(key ed25519.PrivateKey, port string) (net.Listener, error) {
+	remotePort, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, fmt.Errorf("onion: ungültiger Port %q: %w", port, err)
+	}
+
+	t, err := tor.Start(context.Background(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("onion: tor konnte nicht gestartet werden: %w", err)
+	}
+
+	ctx := context.Background()
+	onion, err := t.Listen(ctx, &tor.ListenConf{
+		Key:         key,
+		RemotePorts: []int{remotePort},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("onion: hidden service konnte nicht erstellt werden: %w", err)
+	}
+
+	fmt.Println(cp("c", "Onion-Adresse: ", onion.ID, ".onion:", port))
+	return onion, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import "sync"
+
+/* ===========================
+   Trust-Pinning (/trust)
+=========================== */
+
+var (
+	trustedMu sync.Mutex
+	trusted   = map[string]string{} // username -> gepinnter Fingerprint
+)
+
+func pinTrust(username, fingerprint string) {
+	trustedMu.Lock()
+	defer trustedMu.Unlock()
+	trusted[username] = fingerprint
+}
+
+func isPinned(username string) bool {
+	trustedMu.Lock()
+	defer trustedMu.Unlock()
+	_, ok := trusted[username]
+	return ok
+}
+
+func isTrusted(username, fingerprint string) bool {
+	trustedMu.Lock()
+	defer trustedMu.Unlock()
+	pinned, ok := trusted[username]
+	if !ok {
+		return true // noch kein Pin gesetzt -> TOFU, Verbindung wird akzeptiert
+	}
+	return pinned == fingerprint
+}
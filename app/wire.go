@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+/* ===========================
+   Verschlüsseltes Wire-Protokoll
+=========================== */
+
+// hello ist die erste Nachricht, die beide Seiten einer Verbindung
+// austauschen: Public Keys plus eine signierte Nonce, damit beide Seiten
+// die Authentizität des Gegenübers prüfen können, bevor ein Secret
+// abgeleitet wird.
+type hello struct {
+	Username string `json:"username"`
+	SignPub  []byte `json:"sign_pub"`
+	KexPub   []byte `json:"kex_pub"`
+	Nonce    []byte `json:"nonce"`
+	Sig      []byte `json:"sig"`
+	// LastSeenSeq is the highest sequence number the sender has already
+	// logged for this peer, so the other side knows what can safely be
+	// replayed instead of resent from scratch.
+	LastSeenSeq int64 `json:"last_seen_seq"`
+	// ListenPort is the port the sender itself accepts connections on, so
+	// the other side can reconstruct "remote-ip:listen-port" as the
+	// sender's dialable address and reconcile it against anything queued
+	// in its outbox before a username was known.
+	ListenPort string `json:"listen_port"`
+}
+
+// sealedEnvelope transportiert eine NaCl-box-verschlüsselte Message über
+// das Netz. Nonce ist pro Nachricht zufällig.
+type sealedEnvelope struct {
+	Nonce [24]byte `json:"nonce"`
+	Box   []byte   `json:"box"`
+}
+
+// sendHello runs the mutual handshake and returns the peer's kex key,
+// signing key, username and the sequence number up to which they claim to
+// have already seen our messages (for replay on reconnect). peerKeyHint, if
+// known (e.g. the /connect address), is used to report our own
+// last-seen-seq for that peer.
+func sendHello(conn net.Conn, id *Identity, peerKeyHint string) ([32]byte, ed25519.PublicKey, string, int64, string, error) {
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return [32]byte{}, nil, "", 0, "", err
+	}
+	sig := ed25519.Sign(id.SignPriv, nonce[:])
+
+	var ourLastSeen int64
+	if peerKeyHint != "" {
+		ourLastSeen = lastSeenSeq(historyKey(peerKeyHint))
+	}
+
+	enc := json.NewEncoder(conn)
+	if err := enc.Encode(hello{
+		Username:    id.Username,
+		SignPub:     id.SignPub,
+		KexPub:      id.KexPub[:],
+		Nonce:       nonce[:],
+		Sig:         sig,
+		LastSeenSeq: ourLastSeen,
+		ListenPort:  myListenPort,
+	}); err != nil {
+		return [32]byte{}, nil, "", 0, "", err
+	}
+
+	var peer hello
+	if err := json.NewDecoder(conn).Decode(&peer); err != nil {
+		return [32]byte{}, nil, "", 0, "", err
+	}
+	if !ed25519.Verify(peer.SignPub, peer.Nonce, peer.Sig) {
+		return [32]byte{}, nil, "", 0, "", errors.New("wire: ungültige Signatur im Handshake")
+	}
+	if fp := fingerprintOf(peer.SignPub, peer.KexPub); !isTrusted(peer.Username, fp) && isPinned(peer.Username) {
+		return [32]byte{}, nil, "", 0, "", fmt.Errorf("wire: Fingerprint von %q stimmt nicht mit gepinntem Schlüssel überein: %s", peer.Username, fp)
+	}
+
+	var peerKex [32]byte
+	copy(peerKex[:], peer.KexPub)
+	return peerKex, ed25519.PublicKey(peer.SignPub), peer.Username, peer.LastSeenSeq, peer.ListenPort, nil
+}
+
+func sealMessage(msg Message, ourKexPriv *[32]byte, peerKexPub *[32]byte) (sealedEnvelope, error) {
+	plain, err := json.Marshal(msg)
+	if err != nil {
+		return sealedEnvelope{}, err
+	}
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return sealedEnvelope{}, err
+	}
+	sealed := box.Seal(nil, plain, &nonce, peerKexPub, ourKexPriv)
+	return sealedEnvelope{Nonce: nonce, Box: sealed}, nil
+}
+
+func openMessage(env sealedEnvelope, ourKexPriv *[32]byte, peerKexPub *[32]byte) (Message, error) {
+	plain, ok := box.Open(nil, env.Box, &env.Nonce, peerKexPub, ourKexPriv)
+	if !ok {
+		return Message{}, errors.New("wire: Nachricht konnte nicht entschlüsselt werden")
+	}
+	var msg Message
+	if err := json.Unmarshal(plain, &msg); err != nil {
+		return Message{}, err
+	}
+	return msg, nil
+}